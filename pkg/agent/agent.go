@@ -4,18 +4,36 @@ import (
 	"crypto/ecdsa"
 	"crypto/x509"
 	"errors"
+	"path"
 	"sync"
 	"syscall"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spiffe/spire/pkg/agent/attestor"
 	"github.com/spiffe/spire/pkg/agent/catalog"
 	"github.com/spiffe/spire/pkg/agent/endpoints"
 	"github.com/spiffe/spire/pkg/agent/manager"
-	"path"
+	"github.com/spiffe/spire/pkg/agent/manager/cache"
+	"github.com/spiffe/spire/pkg/agent/manager/cache/lru"
 
 	tomb "gopkg.in/tomb.v2"
 )
 
+// cacheBackendFactory translates the agent's cache_backend HCL block into
+// the manager.CacheBackendFactory it consumes. An empty/unrecognized
+// Backend falls back to manager.New's own default (cache/inmemory).
+func cacheBackendFactory(c CacheBackendConfig) manager.CacheBackendFactory {
+	switch c.Backend {
+	case "lru":
+		maxEntries := c.MaxEntries
+		return func(log logrus.FieldLogger, metrics cache.Metrics) cache.Cache {
+			return lru.New(log, metrics, maxEntries)
+		}
+	default:
+		return nil
+	}
+}
+
 type Agent struct {
 	c   *Config
 	t   *tomb.Tomb
@@ -120,9 +138,12 @@ func (a *Agent) startManager(svid *x509.Certificate, key *ecdsa.PrivateKey, bund
 		Bundle:          bundle,
 		TrustDomain:     a.c.TrustDomain,
 		ServerAddr:      a.c.ServerAddress,
+		ServerAddresses: a.c.ServerAddresses,
 		Log:             a.c.Log,
 		BundleCachePath: path.Join(a.c.DataDir, "bundle.der"),
 		SVIDCachePath:   path.Join(a.c.DataDir, "agent_svid.der"),
+		CacheBackend:    cacheBackendFactory(a.c.CacheBackend),
+		MetricsSink:     a.c.MetricsSink,
 	}
 
 	mgr, err := manager.New(mgrConfig)
@@ -136,11 +157,12 @@ func (a *Agent) startManager(svid *x509.Certificate, key *ecdsa.PrivateKey, bund
 // TODO: Shouldn't need to pass bundle here
 func (a *Agent) startEndpoints(bundle []*x509.Certificate) error {
 	config := &endpoints.Config{
-		Bundle:   bundle,
-		BindAddr: a.c.BindAddress,
-		Catalog:  a.Catalog,
-		Manager:  a.Manager,
-		Log:      a.c.Log.WithField("subsystem_name", "endpoints"),
+		Bundle:      bundle,
+		BindAddr:    a.c.BindAddress,
+		Catalog:     a.Catalog,
+		Manager:     a.Manager,
+		Log:         a.c.Log.WithField("subsystem_name", "endpoints"),
+		MetricsSink: a.c.MetricsSink,
 	}
 
 	e := endpoints.New(config)
@@ -0,0 +1,123 @@
+// Package grpcmw provides the gRPC interceptor chain shared by the agent's
+// outgoing manager streams and its workload API server: panic recovery so a
+// single bad handler doesn't take the whole agent down, and a metrics
+// interceptor so call counts, error counts and latency are visible per
+// method regardless of which endpoint is involved.
+package grpcmw
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sink is the pluggable destination for the metrics interceptors, so users
+// can drive Prometheus, statsd, or anything else from agent.Config.
+type Sink interface {
+	// IncCalls is called once per RPC.
+	IncCalls(method string)
+	// IncErrors is called once per RPC that returned a non-nil error.
+	IncErrors(method string, err error)
+	// ObserveLatencyMillis records the RPC's duration in milliseconds. It's
+	// a float so sub-millisecond calls still register instead of being
+	// truncated to a misleading 0ms bucket.
+	ObserveLatencyMillis(method string, millis float64)
+}
+
+// NopSink discards everything. It's the default when no Sink is configured.
+type NopSink struct{}
+
+func (NopSink) IncCalls(string)                      {}
+func (NopSink) IncErrors(string, error)              {}
+func (NopSink) ObserveLatencyMillis(string, float64) {}
+
+// RecoveryUnaryServerInterceptor recovers from a panic inside a unary
+// handler, logs the stack, and converts it to a codes.Internal error
+// instead of crashing the agent.
+func RecoveryUnaryServerInterceptor(log logrus.FieldLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.WithField("method", info.FullMethod).WithField("panic", r).Error(string(debug.Stack()))
+				err = status.Errorf(codes.Internal, "panic handling %s: %v", info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor is RecoveryUnaryServerInterceptor's
+// counterpart for streaming handlers, e.g. FetchSVID.
+func RecoveryStreamServerInterceptor(log logrus.FieldLogger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.WithField("method", info.FullMethod).WithField("panic", r).Error(string(debug.Stack()))
+				err = status.Errorf(codes.Internal, "panic handling %s: %v", info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// MetricsUnaryServerInterceptor records call count, error count and latency
+// for each unary RPC served by the agent's workload API.
+func MetricsUnaryServerInterceptor(sink Sink) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observe(sink, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// MetricsStreamServerInterceptor is MetricsUnaryServerInterceptor's
+// counterpart for streaming handlers.
+func MetricsStreamServerInterceptor(sink Sink) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observe(sink, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// MetricsUnaryClientInterceptor records call count, error count and latency
+// for unary RPCs issued against the SPIRE server.
+func MetricsUnaryClientInterceptor(sink Sink) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observe(sink, method, start, err)
+		return err
+	}
+}
+
+// MetricsStreamClientInterceptor is MetricsUnaryClientInterceptor's
+// counterpart for streaming calls, e.g. the manager's FetchSVID stream. Only
+// the opening of the stream is measured: the latency and error recorded are
+// those of establishing it, not of its later Send/Recv calls.
+func MetricsStreamClientInterceptor(sink Sink) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		observe(sink, method, start, err)
+		return stream, err
+	}
+}
+
+func observe(sink Sink, method string, start time.Time, err error) {
+	if sink == nil {
+		sink = NopSink{}
+	}
+	sink.IncCalls(method)
+	if err != nil {
+		sink.IncErrors(method, err)
+	}
+	sink.ObserveLatencyMillis(method, float64(time.Since(start))/float64(time.Millisecond))
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"crypto/x509"
+	"net"
+	"net/url"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/spire/pkg/agent/common/grpcmw"
+)
+
+// CacheBackendConfig selects and sizes the manager's SVID cache from HCL,
+// e.g.:
+//
+//	cache_backend = "lru" {
+//		max_entries = 10000
+//	}
+//
+// Backend is one of "inmemory" (the default) or "lru"; MaxEntries is only
+// consulted when Backend is "lru".
+type CacheBackendConfig struct {
+	Backend    string `hcl:"backend"`
+	MaxEntries int    `hcl:"max_entries"`
+}
+
+// Config holds the agent's top-level configuration, assembled by the agent
+// CLI from its HCL file and flags.
+type Config struct {
+	// ServerAddress is kept for single-server deployments. ServerAddresses
+	// supersedes it whenever it lists at least one upstream SPIRE server.
+	ServerAddress   net.Addr
+	ServerAddresses []net.Addr
+
+	TrustDomain url.URL
+	TrustBundle []*x509.Certificate
+	JoinToken   string
+	DataDir     string
+	BindAddress net.Addr
+	Umask       int
+
+	// CacheBackend configures the manager's SVID cache. A zero value falls
+	// back to the unbounded cache/inmemory backend.
+	CacheBackend CacheBackendConfig
+
+	// MetricsSink receives per-method call count, error count and latency
+	// for every RPC the agent issues or serves, both on its manager client
+	// conns and its workload API server.
+	MetricsSink grpcmw.Sink
+
+	Log logrus.FieldLogger
+}
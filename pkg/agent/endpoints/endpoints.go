@@ -0,0 +1,105 @@
+// Package endpoints runs the agent's workload API: the gRPC server local
+// workloads call to fetch their SVIDs. The server is wrapped in the shared
+// grpcmw recovery/metrics interceptor chain so a panic in a single workload
+// handler can't take the whole agent down. Each FetchX509SVID stream
+// subscribes to the manager's cache.Cache for the life of the call, so a
+// rotated SVID reaches the workload holding that stream open immediately
+// rather than on its next poll; see the workload subpackage for the
+// handler that does the subscribing.
+package endpoints
+
+import (
+	"crypto/x509"
+	"net"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/spire/pkg/agent/catalog"
+	"github.com/spiffe/spire/pkg/agent/common/grpcmw"
+	"github.com/spiffe/spire/pkg/agent/endpoints/workload"
+	"github.com/spiffe/spire/pkg/agent/manager"
+	workloadpb "github.com/spiffe/spire/proto/api/workload"
+
+	"google.golang.org/grpc"
+)
+
+// Config configures the workload API endpoints.
+type Config struct {
+	Bundle      []*x509.Certificate
+	BindAddr    net.Addr
+	Catalog     catalog.Catalog
+	Manager     manager.Manager
+	Log         logrus.FieldLogger
+	MetricsSink grpcmw.Sink
+}
+
+// Endpoints runs the workload API server.
+type Endpoints interface {
+	// Start starts serving the workload API. It returns once the listener
+	// is up; Serve errors are reported through Wait.
+	Start() error
+	// Wait blocks until the server stops, returning any error it stopped
+	// with.
+	Wait() error
+	// Shutdown stops the server.
+	Shutdown()
+}
+
+type endpoints struct {
+	c *Config
+
+	server   *grpc.Server
+	listener net.Listener
+	errCh    chan error
+}
+
+// New creates workload API Endpoints from the given Config.
+func New(c *Config) Endpoints {
+	return &endpoints{
+		c:     c,
+		errCh: make(chan error, 1),
+	}
+}
+
+// Start binds the workload API listener, wires up the recovery and metrics
+// interceptor chain, and registers the FetchX509SVID handler.
+func (e *endpoints) Start() error {
+	l, err := net.Listen(e.c.BindAddr.Network(), e.c.BindAddr.String())
+	if err != nil {
+		return err
+	}
+	e.listener = l
+
+	e.server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			grpcmw.RecoveryUnaryServerInterceptor(e.c.Log),
+			grpcmw.MetricsUnaryServerInterceptor(e.c.MetricsSink),
+		),
+		grpc.ChainStreamInterceptor(
+			grpcmw.RecoveryStreamServerInterceptor(e.c.Log),
+			grpcmw.MetricsStreamServerInterceptor(e.c.MetricsSink),
+		),
+	)
+
+	workloadpb.RegisterSpiffeWorkloadAPIServer(e.server, &workload.Handler{
+		Manager: e.c.Manager,
+		Catalog: e.c.Catalog,
+		Log:     e.c.Log.WithField("subsystem_name", "workload_api"),
+	})
+
+	go func() {
+		e.errCh <- e.server.Serve(e.listener)
+	}()
+
+	return nil
+}
+
+func (e *endpoints) Wait() error {
+	return <-e.errCh
+}
+
+// Shutdown stops the gRPC server.
+func (e *endpoints) Shutdown() {
+	if e.server != nil {
+		e.server.GracefulStop()
+	}
+}
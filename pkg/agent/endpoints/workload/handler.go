@@ -0,0 +1,115 @@
+// Package workload implements the SPIFFE Workload API's FetchX509SVID RPC,
+// the call local workloads hold open to receive their SVID and have it
+// refreshed for as long as they're connected.
+package workload
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/spire/pkg/agent/catalog"
+	"github.com/spiffe/spire/pkg/agent/manager"
+	"github.com/spiffe/spire/pkg/agent/manager/cache"
+	"github.com/spiffe/spire/pkg/common/util"
+	"github.com/spiffe/spire/proto/api/workload"
+
+	"google.golang.org/grpc/peer"
+)
+
+// errNoIdentityIssued is returned when the caller's workload attestation
+// selectors don't match any registration entry the agent has an SVID for.
+var errNoIdentityIssued = errors.New("workload: no identity issued for this workload")
+
+// Handler implements workload.SpiffeWorkloadAPIServer.
+type Handler struct {
+	Manager manager.Manager
+	Catalog catalog.Catalog
+	Log     logrus.FieldLogger
+}
+
+// FetchX509SVID streams every cache entry matching the calling workload's
+// selectors, then blocks on the manager's cache.Subscribe and streams the
+// refreshed set each time the manager rotates one of them, for the life of
+// the call.
+func (h *Handler) FetchX509SVID(_ *workload.X509SVIDRequest, stream workload.SpiffeWorkloadAPI_FetchX509SVIDServer) error {
+	ctx := stream.Context()
+
+	selectors, err := h.attestCaller(ctx)
+	if err != nil {
+		h.Log.WithError(err).Error("workload attestation failed")
+		return err
+	}
+
+	updates, cancel := h.Manager.Cache().Subscribe(selectors)
+	defer cancel()
+
+	if err := h.sendResponse(stream, selectors); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := h.sendResponse(stream, selectors); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// attestCaller resolves the calling workload's selectors from its peer
+// credentials via the agent's configured workload attestors.
+func (h *Handler) attestCaller(ctx context.Context) (cache.Selectors, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, errors.New("workload: no peer information on stream context")
+	}
+	return h.Catalog.WorkloadAttestors().Attest(ctx, p)
+}
+
+// sendResponse gathers every cache entry matching selectors and sends them
+// to the stream as a single X509SVIDResponse.
+func (h *Handler) sendResponse(stream workload.SpiffeWorkloadAPI_FetchX509SVIDServer, selectors cache.Selectors) error {
+	resp, err := h.composeResponse(selectors)
+	if err != nil {
+		return err
+	}
+	return stream.Send(resp)
+}
+
+func (h *Handler) composeResponse(selectors cache.Selectors) (*workload.X509SVIDResponse, error) {
+	var svids []*workload.X509SVID
+	for entry := range h.Manager.Cache().Entries() {
+		if entry.RegistrationEntry == nil || entry.SVID == nil || entry.PrivateKey == nil {
+			continue
+		}
+		if !util.SelectorsSuperset(entry.RegistrationEntry.Selectors, selectors) {
+			continue
+		}
+
+		keyDER, err := x509.MarshalECPrivateKey(entry.PrivateKey)
+		if err != nil {
+			h.Log.WithError(err).WithField("spiffe_id", entry.RegistrationEntry.SpiffeId).Error("failed to marshal SVID private key")
+			continue
+		}
+
+		svids = append(svids, &workload.X509SVID{
+			SpiffeId:    entry.RegistrationEntry.SpiffeId,
+			X509Svid:    entry.SVID.Raw,
+			X509SvidKey: keyDER,
+			Bundle:      entry.Bundles[entry.RegistrationEntry.SpiffeId],
+		})
+	}
+
+	if len(svids) == 0 {
+		return nil, errNoIdentityIssued
+	}
+	return &workload.X509SVIDResponse{Svids: svids}, nil
+}
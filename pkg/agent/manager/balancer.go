@@ -0,0 +1,202 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// errNoServerAddresses is returned by Get when the balancer was constructed
+// with an empty address list, instead of panicking on a modulo by zero.
+var errNoServerAddresses = errors.New("manager: no SPIRE server addresses configured")
+
+const (
+	// defaultProbeInterval is how often a healthy endpoint is re-probed and
+	// how often an unhealthy one is retried once its cooldown has elapsed.
+	defaultProbeInterval = 10 * time.Second
+	// defaultCooldown is how long an endpoint is kept out of Pick() after a
+	// failed probe or a Unavailable/DeadlineExceeded error from a live call.
+	defaultCooldown = 30 * time.Second
+	// healthProbeTimeout bounds how long a single health probe dial+stream
+	// is allowed to take before the endpoint is considered unhealthy.
+	healthProbeTimeout = 5 * time.Second
+)
+
+// serverEndpoint tracks the health of a single upstream SPIRE server.
+type serverEndpoint struct {
+	addr      grpc.Address
+	healthy   bool
+	downUntil time.Time
+}
+
+// healthBalancer is a grpc.Balancer that spreads FetchSVID streams across a
+// set of SPIRE server addresses. It probes each address on a configurable
+// interval, excludes unhealthy ones from Pick(), and re-admits them once a
+// probe succeeds again. This mirrors the approach etcd's clientv3 health
+// balancer takes, so that a single down server doesn't take every
+// SPIFFEID's stream down with it.
+type healthBalancer struct {
+	mu   sync.Mutex
+	eps  []*serverEndpoint
+	next int
+
+	notifyCh chan []grpc.Address
+	closeCh  chan struct{}
+
+	probeInterval time.Duration
+	cooldown      time.Duration
+	probe         func(addr string) error
+}
+
+// newHealthBalancer creates a balancer over addrs. probe is invoked on
+// defaultProbeInterval for every endpoint; a non-nil error marks that
+// endpoint unhealthy for the cooldown window, excluding it from Pick().
+func newHealthBalancer(addrs []net.Addr, probe func(addr string) error) *healthBalancer {
+	eps := make([]*serverEndpoint, 0, len(addrs))
+	for _, a := range addrs {
+		eps = append(eps, &serverEndpoint{addr: grpc.Address{Addr: a.String()}, healthy: true})
+	}
+	return &healthBalancer{
+		eps:           eps,
+		notifyCh:      make(chan []grpc.Address, 1),
+		closeCh:       make(chan struct{}),
+		probeInterval: defaultProbeInterval,
+		cooldown:      defaultCooldown,
+		probe:         probe,
+	}
+}
+
+// Start implements grpc.Balancer.
+func (b *healthBalancer) Start(target string, config grpc.BalancerConfig) error {
+	b.notify()
+	go b.run()
+	return nil
+}
+
+// Up implements grpc.Balancer. SPIRE's balancer population is static (it
+// comes from ServerAddresses, or the single ServerAddr, at construction time
+// rather than a resolver; no DNS SRV lookup is done), so there's nothing to
+// do beyond acknowledging the subconn.
+func (b *healthBalancer) Up(addr grpc.Address) func(error) {
+	return func(error) {}
+}
+
+// Get implements grpc.Balancer, returning the next healthy endpoint in
+// round-robin order. Callers that see Unavailable or DeadlineExceeded back
+// from the returned address call markUnhealthy (the manager's sync loop
+// does this on such a FetchSVID Recv failure, and on a failed liveness
+// probe) so it is excluded from
+// subsequent picks instead of waiting for the next scheduled probe.
+func (b *healthBalancer) Get(ctx context.Context, opts grpc.BalancerGetOptions) (grpc.Address, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.eps)
+	if n == 0 {
+		return grpc.Address{}, func() {}, errNoServerAddresses
+	}
+
+	for i := 0; i < n; i++ {
+		ep := b.eps[b.next%n]
+		b.next++
+		if ep.healthy {
+			return ep.addr, func() {}, nil
+		}
+	}
+	// Nothing healthy: fail open onto the next endpoint in rotation rather
+	// than refusing the call outright, so a brief all-down window doesn't
+	// wedge every FetchSVID stream.
+	ep := b.eps[b.next%n]
+	b.next++
+	return ep.addr, func() {}, nil
+}
+
+// Notify implements grpc.Balancer.
+func (b *healthBalancer) Notify() <-chan []grpc.Address {
+	return b.notifyCh
+}
+
+// Close implements grpc.Balancer.
+func (b *healthBalancer) Close() error {
+	close(b.closeCh)
+	return nil
+}
+
+// Current returns the address most recently handed out by Get, for logging.
+func (b *healthBalancer) Current() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.eps)
+	if n == 0 {
+		return ""
+	}
+	return b.eps[(b.next-1+n)%n].addr.Addr
+}
+
+func (b *healthBalancer) run() {
+	ticker := time.NewTicker(b.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.probeAll()
+		case <-b.closeCh:
+			return
+		}
+	}
+}
+
+func (b *healthBalancer) probeAll() {
+	b.mu.Lock()
+	eps := make([]*serverEndpoint, len(b.eps))
+	copy(eps, b.eps)
+	b.mu.Unlock()
+
+	for _, ep := range eps {
+		err := b.probe(ep.addr.Addr)
+		b.mu.Lock()
+		if err != nil {
+			ep.healthy = false
+			ep.downUntil = time.Now().Add(b.cooldown)
+		} else if !ep.healthy && time.Now().After(ep.downUntil) {
+			ep.healthy = true
+		} else if !ep.healthy && time.Now().Before(ep.downUntil) {
+			// Still cooling down; leave it marked unhealthy.
+		} else {
+			ep.healthy = true
+		}
+		b.mu.Unlock()
+	}
+}
+
+// markUnhealthy excludes addr from Pick() for the cooldown window. It is
+// called by the manager's sync loop when a live FetchSVID Recv returns
+// Unavailable or DeadlineExceeded, or a liveness probe fails, so a broken
+// server doesn't have to wait for the next scheduled probe to be dropped
+// from rotation.
+func (b *healthBalancer) markUnhealthy(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ep := range b.eps {
+		if ep.addr.Addr == addr {
+			ep.healthy = false
+			ep.downUntil = time.Now().Add(b.cooldown)
+			return
+		}
+	}
+}
+
+func (b *healthBalancer) notify() {
+	addrs := make([]grpc.Address, len(b.eps))
+	for i, ep := range b.eps {
+		addrs[i] = ep.addr
+	}
+	select {
+	case b.notifyCh <- addrs:
+	default:
+	}
+}
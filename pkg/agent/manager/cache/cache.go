@@ -3,10 +3,7 @@ package cache
 import (
 	"crypto/ecdsa"
 	"crypto/x509"
-	"sync"
 
-	"github.com/sirupsen/logrus"
-	"github.com/spiffe/spire/pkg/common/util"
 	"github.com/spiffe/spire/proto/common"
 )
 
@@ -24,6 +21,10 @@ type Entry struct {
 	Bundles map[string][]byte
 }
 
+// Cache is consumed by the manager to store SVIDs fetched for the agent's
+// registration entries. Concrete backends live in sub-packages (e.g.
+// cache/inmemory, cache/lru) so operators can pick the one that fits their
+// workload count and memory budget.
 type Cache interface {
 	// Entry gets the first cache entry for the specified RegistrationEntry.
 	Entry(regEntry *common.RegistrationEntry) *Entry
@@ -39,85 +40,32 @@ type Cache interface {
 	Entries() chan Entry
 	// IsEmpty returns true if this cache doesn't have any entry.
 	IsEmpty() bool
+	// Subscribe registers interest in entries whose registration entry's
+	// selectors are a superset of selectors, returning a channel of Events
+	// fanned out from SetEntry/DeleteEntry/DeleteEntries and a CancelFunc
+	// to unregister it.
+	Subscribe(selectors Selectors) (<-chan Event, CancelFunc)
 }
 
-type cacheImpl struct {
-	// Map keyed by a combination of SpiffeId + ParentId + Selectors holding a list of
-	// Entry instances ordered by SVID expiration date.
-	cache map[string][]Entry
-	log   logrus.FieldLogger
-	m     sync.Mutex
+// Metrics is the hook cache backends emit through so operators can observe
+// (and size) the cache from the outside, e.g. when deciding on an LRU cap.
+type Metrics interface {
+	// Hit is called whenever Entry() finds a cached entry.
+	Hit()
+	// Miss is called whenever Entry() finds no cached entry.
+	Miss()
+	// Eviction is called whenever a backend drops an entry to stay within
+	// its size cap, as opposed to an explicit DeleteEntry/DeleteEntries.
+	Eviction()
+	// Size reports the current number of cache entries.
+	Size(n int)
 }
 
-// New creates a new Cache.
-func New(log logrus.FieldLogger) Cache {
-	return &cacheImpl{
-		cache: make(map[string][]Entry),
-		log:   log.WithField("subsystem_name", "cache"),
-	}
-}
-
-func (c *cacheImpl) Entries() chan Entry {
-	c.m.Lock()
-	defer c.m.Unlock()
-	entries := make(chan Entry, len(c.cache))
-	for _, e := range c.cache {
-		// Only return the first element for each array of entries because it is the
-		// in force entry.
-		entries <- e[0]
-	}
-	close(entries)
-	return entries
-}
+// NopMetrics is a Metrics implementation that discards everything. Backends
+// should default to it when no Metrics is configured.
+type NopMetrics struct{}
 
-func (c *cacheImpl) Entry(regEntry *common.RegistrationEntry) *Entry {
-	key := util.DeriveRegEntryhash(regEntry)
-	c.m.Lock()
-	defer c.m.Unlock()
-	if entries, found := c.cache[key]; found {
-		return &entries[0]
-	}
-	return nil
-}
-
-func (c *cacheImpl) SetEntry(entry *Entry) {
-	c.m.Lock()
-	defer c.m.Unlock()
-	key := util.DeriveRegEntryhash(entry.RegistrationEntry)
-	c.cache[key] = append(c.cache[key], *entry)
-	return
-
-}
-
-func (c *cacheImpl) DeleteEntries(regEntry *common.RegistrationEntry) int {
-	c.m.Lock()
-	defer c.m.Unlock()
-	key := util.DeriveRegEntryhash(regEntry)
-	if entries, found := c.cache[key]; found {
-		delete(c.cache, key)
-		return len(entries)
-	}
-	return 0
-}
-
-func (c *cacheImpl) DeleteEntry(regEntry *common.RegistrationEntry) bool {
-	c.m.Lock()
-	defer c.m.Unlock()
-	key := util.DeriveRegEntryhash(regEntry)
-	if entries, found := c.cache[key]; found {
-		if len(entries) > 0 {
-			c.cache[key] = entries[1:]
-			if len(c.cache[key]) == 0 {
-				delete(c.cache, key)
-			}
-			return true
-		}
-	}
-	return false
-}
-
-func (c *cacheImpl) IsEmpty() bool {
-	c.m.Lock()
-	defer c.m.Unlock()
-	return len(c.cache) == 0
-}
+func (NopMetrics) Hit()      {}
+func (NopMetrics) Miss()     {}
+func (NopMetrics) Eviction() {}
+func (NopMetrics) Size(int)  {}
@@ -0,0 +1,127 @@
+// Package inmemory provides the default cache.Cache implementation: an
+// unbounded map keyed by registration entry, with no eviction policy. It is
+// a straightforward move of the cache package's original implementation,
+// kept around as the default for agents that don't need a bounded memory
+// footprint. See cache/lru for a bounded alternative.
+package inmemory
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/spire/pkg/agent/manager/cache"
+	"github.com/spiffe/spire/pkg/common/util"
+	"github.com/spiffe/spire/proto/common"
+)
+
+type inmemoryCache struct {
+	// Map keyed by a combination of SpiffeId + ParentId + Selectors holding a list of
+	// Entry instances ordered by SVID expiration date.
+	cache   map[string][]cache.Entry
+	log     logrus.FieldLogger
+	metrics cache.Metrics
+	subs    cache.Subscribers
+	m       sync.Mutex
+}
+
+// New creates a new unbounded, in-memory Cache.
+func New(log logrus.FieldLogger, metrics cache.Metrics) cache.Cache {
+	if metrics == nil {
+		metrics = cache.NopMetrics{}
+	}
+	l := log.WithField("subsystem_name", "cache")
+	return &inmemoryCache{
+		cache:   make(map[string][]cache.Entry),
+		log:     l,
+		metrics: metrics,
+		subs:    cache.NewSubscribers(l),
+	}
+}
+
+func (c *inmemoryCache) Subscribe(selectors cache.Selectors) (<-chan cache.Event, cache.CancelFunc) {
+	return c.subs.Subscribe(selectors)
+}
+
+func (c *inmemoryCache) Entries() chan cache.Entry {
+	c.m.Lock()
+	defer c.m.Unlock()
+	entries := make(chan cache.Entry, len(c.cache))
+	for _, e := range c.cache {
+		// Only return the first element for each array of entries because it is the
+		// in force entry.
+		entries <- e[0]
+	}
+	close(entries)
+	return entries
+}
+
+func (c *inmemoryCache) Entry(regEntry *common.RegistrationEntry) *cache.Entry {
+	key := util.DeriveRegEntryhash(regEntry)
+	c.m.Lock()
+	defer c.m.Unlock()
+	if entries, found := c.cache[key]; found {
+		c.metrics.Hit()
+		return &entries[0]
+	}
+	c.metrics.Miss()
+	return nil
+}
+
+func (c *inmemoryCache) SetEntry(entry *cache.Entry) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key := util.DeriveRegEntryhash(entry.RegistrationEntry)
+	eventType := cache.EventTypeAdded
+	if _, found := c.cache[key]; found {
+		eventType = cache.EventTypeUpdated
+	}
+	c.cache[key] = append(c.cache[key], *entry)
+	c.metrics.Size(len(c.cache))
+
+	c.subs.Notify(eventType, *entry)
+}
+
+func (c *inmemoryCache) DeleteEntries(regEntry *common.RegistrationEntry) int {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key := util.DeriveRegEntryhash(regEntry)
+	entries, found := c.cache[key]
+	if !found {
+		return 0
+	}
+	delete(c.cache, key)
+	c.metrics.Size(len(c.cache))
+
+	for _, e := range entries {
+		c.subs.Notify(cache.EventTypeRemoved, e)
+	}
+	return len(entries)
+}
+
+func (c *inmemoryCache) DeleteEntry(regEntry *common.RegistrationEntry) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key := util.DeriveRegEntryhash(regEntry)
+	entries, found := c.cache[key]
+	if !found || len(entries) == 0 {
+		return false
+	}
+	removed := entries[0]
+	c.cache[key] = entries[1:]
+	if len(c.cache[key]) == 0 {
+		delete(c.cache, key)
+	}
+	c.metrics.Size(len(c.cache))
+
+	c.subs.Notify(cache.EventTypeRemoved, removed)
+	return true
+}
+
+func (c *inmemoryCache) IsEmpty() bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return len(c.cache) == 0
+}
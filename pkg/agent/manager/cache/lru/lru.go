@@ -0,0 +1,180 @@
+// Package lru provides a Cache backend bounded to a fixed number of
+// registration entries. It keeps entries in a doubly-linked list ordered by
+// recency of access, with a map for O(1) lookup, and evicts the
+// least-recently-fetched entry once the cap is exceeded. It's meant for
+// agents with a large workload count on memory-constrained hosts, where an
+// unbounded cache/inmemory cache would grow without limit.
+package lru
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/spire/pkg/agent/manager/cache"
+	"github.com/spiffe/spire/pkg/common/util"
+	"github.com/spiffe/spire/proto/common"
+)
+
+// DefaultMaxEntries is used when New is given a maxEntries of zero or less.
+const DefaultMaxEntries = 1000
+
+// node is the value stored at each list.Element, holding every cache.Entry
+// for a given registration entry in the same order cache/inmemory keeps
+// them (i.e. ordered by SVID expiration date, with index 0 in force).
+type node struct {
+	key     string
+	entries []cache.Entry
+}
+
+type lruCache struct {
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	log        logrus.FieldLogger
+	metrics    cache.Metrics
+	subs       cache.Subscribers
+	m          sync.Mutex
+}
+
+// New creates a Cache that holds at most maxEntries registration entries,
+// evicting the least-recently-fetched one once the cap is exceeded. A
+// maxEntries of zero falls back to DefaultMaxEntries.
+func New(log logrus.FieldLogger, metrics cache.Metrics, maxEntries int) cache.Cache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	if metrics == nil {
+		metrics = cache.NopMetrics{}
+	}
+	l := log.WithField("subsystem_name", "cache")
+	return &lruCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		log:        l,
+		metrics:    metrics,
+		subs:       cache.NewSubscribers(l),
+	}
+}
+
+func (c *lruCache) Subscribe(selectors cache.Selectors) (<-chan cache.Event, cache.CancelFunc) {
+	return c.subs.Subscribe(selectors)
+}
+
+func (c *lruCache) Entry(regEntry *common.RegistrationEntry) *cache.Entry {
+	key := util.DeriveRegEntryhash(regEntry)
+	c.m.Lock()
+	defer c.m.Unlock()
+	el, found := c.items[key]
+	if !found {
+		c.metrics.Miss()
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	c.metrics.Hit()
+	return &el.Value.(*node).entries[0]
+}
+
+func (c *lruCache) SetEntry(entry *cache.Entry) {
+	key := util.DeriveRegEntryhash(entry.RegistrationEntry)
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var evicted *node
+	eventType := cache.EventTypeAdded
+	if el, found := c.items[key]; found {
+		el.Value.(*node).entries = append(el.Value.(*node).entries, *entry)
+		c.ll.MoveToFront(el)
+		eventType = cache.EventTypeUpdated
+	} else {
+		el := c.ll.PushFront(&node{key: key, entries: []cache.Entry{*entry}})
+		c.items[key] = el
+		c.metrics.Size(c.ll.Len())
+
+		if c.ll.Len() > c.maxEntries {
+			evicted = c.evictOldest()
+		}
+	}
+
+	c.subs.Notify(eventType, *entry)
+	if evicted != nil {
+		for _, e := range evicted.entries {
+			c.subs.Notify(cache.EventTypeRemoved, e)
+		}
+	}
+}
+
+func (c *lruCache) DeleteEntries(regEntry *common.RegistrationEntry) int {
+	key := util.DeriveRegEntryhash(regEntry)
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	el, found := c.items[key]
+	if !found {
+		return 0
+	}
+	n := el.Value.(*node)
+	c.ll.Remove(el)
+	delete(c.items, key)
+	c.metrics.Size(c.ll.Len())
+
+	for _, e := range n.entries {
+		c.subs.Notify(cache.EventTypeRemoved, e)
+	}
+	return len(n.entries)
+}
+
+func (c *lruCache) DeleteEntry(regEntry *common.RegistrationEntry) bool {
+	key := util.DeriveRegEntryhash(regEntry)
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	el, found := c.items[key]
+	if !found || len(el.Value.(*node).entries) == 0 {
+		return false
+	}
+	n := el.Value.(*node)
+	removed := n.entries[0]
+	n.entries = n.entries[1:]
+	if len(n.entries) == 0 {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		c.metrics.Size(c.ll.Len())
+	}
+
+	c.subs.Notify(cache.EventTypeRemoved, removed)
+	return true
+}
+
+func (c *lruCache) Entries() chan cache.Entry {
+	c.m.Lock()
+	defer c.m.Unlock()
+	entries := make(chan cache.Entry, c.ll.Len())
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		entries <- el.Value.(*node).entries[0]
+	}
+	close(entries)
+	return entries
+}
+
+func (c *lruCache) IsEmpty() bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.ll.Len() == 0
+}
+
+// evictOldest drops and returns the least-recently-fetched entry, or nil if
+// the cache is empty. Callers must hold c.m.
+func (c *lruCache) evictOldest() *node {
+	oldest := c.ll.Back()
+	if oldest == nil {
+		return nil
+	}
+	n := oldest.Value.(*node)
+	c.ll.Remove(oldest)
+	delete(c.items, n.key)
+	c.metrics.Eviction()
+	c.metrics.Size(c.ll.Len())
+	return n
+}
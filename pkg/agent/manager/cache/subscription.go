@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/spire/pkg/common/util"
+)
+
+// subscriberBufferSize bounds how many events a slow subscriber can fall
+// behind by before its oldest buffered event is dropped in favor of the
+// new one.
+const subscriberBufferSize = 64
+
+// EventType describes what happened to an Entry being reported over a
+// Subscribe channel.
+type EventType int
+
+const (
+	EventTypeAdded EventType = iota
+	EventTypeUpdated
+	EventTypeRemoved
+)
+
+// Event is sent to a subscriber when a cache entry whose registration
+// entry's selectors are a superset of the subscription's selectors is
+// added, updated or removed.
+type Event struct {
+	Type  EventType
+	Entry Entry
+}
+
+// CancelFunc unregisters a subscription. It is safe to call more than once.
+type CancelFunc func()
+
+type subscriber struct {
+	selectors Selectors
+	ch        chan Event
+}
+
+// Subscribers fans cache mutations out to interested callers. Cache backends
+// (inmemory, lru) hold one rather than duplicating this bookkeeping.
+type Subscribers struct {
+	mu   sync.Mutex
+	log  logrus.FieldLogger
+	subs []*subscriber
+}
+
+// NewSubscribers creates an empty Subscribers set.
+func NewSubscribers(log logrus.FieldLogger) Subscribers {
+	return Subscribers{log: log}
+}
+
+// Subscribe registers interest in entries whose selectors are a superset of
+// selectors, returning the event channel and a CancelFunc that unregisters
+// it and closes the channel.
+func (s *Subscribers) Subscribe(selectors Selectors) (<-chan Event, CancelFunc) {
+	sub := &subscriber{selectors: selectors, ch: make(chan Event, subscriberBufferSize)}
+
+	s.mu.Lock()
+	s.subs = append(s.subs, sub)
+	s.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for i, existing := range s.subs {
+				if existing == sub {
+					s.subs = append(s.subs[:i], s.subs[i+1:]...)
+					break
+				}
+			}
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
+
+// Notify fans entry out to every subscriber whose selectors it satisfies.
+// Subscribers that can't keep up have their oldest buffered event dropped
+// in favor of this one, with a logged warning rather than blocking the
+// cache mutation that triggered it.
+//
+// Callers must hold their own cache lock while calling Notify, so that
+// concurrent mutations of the same key are reported to subscribers in the
+// order they actually happened.
+func (s *Subscribers) Notify(eventType EventType, entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ev := Event{Type: eventType, Entry: entry}
+	for _, sub := range s.subs {
+		if !util.SelectorsSuperset(entry.RegistrationEntry.Selectors, sub.selectors) {
+			continue
+		}
+
+		select {
+		case sub.ch <- ev:
+			continue
+		default:
+		}
+
+		select {
+		case <-sub.ch:
+		default:
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+		s.log.WithField("selectors", sub.selectors).Warn("dropping oldest cache event for slow subscriber")
+	}
+}
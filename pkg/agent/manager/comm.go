@@ -5,18 +5,46 @@ import (
 	"crypto/ecdsa"
 	"crypto/tls"
 	"crypto/x509"
+	"math/rand"
 	"sync"
+	"time"
 
 	spiffe_tls "github.com/spiffe/go-spiffe/tls"
+	"github.com/spiffe/spire/pkg/agent/common/grpcmw"
 	"github.com/spiffe/spire/proto/api/node"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
+const (
+	// reconnectBaseDelay and reconnectMaxDelay bound the jittered
+	// exponential backoff used between reconnect attempts.
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = 30 * time.Second
+	// reconnectMaxRetries is how many consecutive reconnect attempts a
+	// client will make before giving up and surfacing the error.
+	reconnectMaxRetries = 10
+)
+
 type client struct {
-	conn   *grpc.ClientConn
-	stream node.Node_FetchSVIDClient
+	conn     *grpc.ClientConn
+	stream   node.Node_FetchSVIDClient
+	balancer *healthBalancer
+
+	spiffeID string
+	svid     *x509.Certificate
+	key      *ecdsa.PrivateKey
+
+	m    *manager
+	pool *clientsPool
+
+	// pingRequested is signaled by clientsPool.Ping and consumed by the
+	// syncClient goroutine that owns this client's conn/stream/balancer,
+	// so a liveness probe never reconnects concurrently with syncClient's
+	// own error handling. Buffered by one: a probe already pending absorbs
+	// a repeat request instead of blocking the pinger.
+	pingRequested chan struct{}
 }
 
 type clientsPool struct {
@@ -26,23 +54,68 @@ type clientsPool struct {
 	m *sync.Mutex
 }
 
-func (m *manager) newGRPCConn(svid *x509.Certificate, key *ecdsa.PrivateKey) (*grpc.ClientConn, error) {
-	var tlsCert []tls.Certificate
-	var tlsConfig *tls.Config
+// newGRPCConn dials the configured set of SPIRE server addresses through a
+// health-checking balancer rather than a single fragile grpc.ClientConn, so
+// a FetchSVID stream can fail over to another server transparently. Each
+// subconn is probed on an interval with a no-op FetchSVID call; unhealthy
+// ones are excluded from Pick() for a cooldown window and re-admitted once a
+// probe succeeds again.
+func (m *manager) newGRPCConn(svid *x509.Certificate, key *ecdsa.PrivateKey) (*grpc.ClientConn, *healthBalancer, error) {
+	if len(m.serverAddrs) == 0 {
+		return nil, nil, errNoServerAddresses
+	}
+
+	tlsConfig := m.tlsConfigFor(svid, key)
+	dialCreds := grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+
+	bal := newHealthBalancer(m.serverAddrs, func(addr string) error {
+		return probeFetchSVID(tlsConfig, addr)
+	})
 
+	conn, err := grpc.Dial(
+		m.serverAddrs[0].String(),
+		dialCreds,
+		grpc.WithBalancer(bal),
+		grpc.WithChainUnaryInterceptor(grpcmw.MetricsUnaryClientInterceptor(m.metricsSink)),
+		grpc.WithChainStreamInterceptor(grpcmw.MetricsStreamClientInterceptor(m.metricsSink)),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, bal, nil
+}
+
+// tlsConfigFor builds the TLS config a client authenticates to the SPIRE
+// server(s) with: svid/key as the client cert, and the current trust bundle
+// pinned to the server's expected SPIFFE ID. Shared by newGRPCConn (to dial)
+// and client.probeLive (to exercise an idle conn's target without disturbing
+// the conn itself).
+func (m *manager) tlsConfigFor(svid *x509.Certificate, key *ecdsa.PrivateKey) *tls.Config {
 	spiffePeer := &spiffe_tls.TLSPeer{
 		SpiffeIDs:  []string{m.serverSPIFFEID},
 		TrustRoots: m.bundleAsCertPool(),
 	}
-	tlsCert = append(tlsCert, tls.Certificate{Certificate: [][]byte{svid.Raw}, PrivateKey: key})
-	tlsConfig = spiffePeer.NewTLSConfig(tlsCert)
-	dialCreds := grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	tlsCert := []tls.Certificate{{Certificate: [][]byte{svid.Raw}, PrivateKey: key}}
+	return spiffePeer.NewTLSConfig(tlsCert)
+}
 
-	conn, err := grpc.Dial(m.serverAddr.String(), dialCreds)
+// probeFetchSVID opens a throwaway FetchSVID stream against addr and closes
+// it immediately; a failure to connect or open the stream is treated as the
+// endpoint being unhealthy.
+func probeFetchSVID(tlsConfig *tls.Config, addr string) error {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), grpc.WithBlock(), grpc.WithTimeout(healthProbeTimeout))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return conn, nil
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	defer cancel()
+	stream, err := node.NewNodeClient(conn).FetchSVID(ctx)
+	if err != nil {
+		return err
+	}
+	return stream.CloseSend()
 }
 
 // newClient adds a new client to the pool and associates it to the specified list of spiffeIDs.
@@ -54,13 +127,13 @@ func (m *manager) newClient(spiffeIDs []string, svid *x509.Certificate, key *ecd
 	}
 	m.mtx.Unlock()
 
-	conn, err := m.newGRPCConn(svid, key)
+	conn, bal, err := m.newGRPCConn(svid, key)
 	if err != nil {
 		return err
 	}
 
 	for _, id := range spiffeIDs {
-		err = m.clients.add(id, conn)
+		err = m.clients.add(m, id, conn, bal, svid, key)
 		if err != nil {
 			conn.Close()
 			return err
@@ -70,7 +143,7 @@ func (m *manager) newClient(spiffeIDs []string, svid *x509.Certificate, key *ecd
 	return nil
 }
 
-func (p *clientsPool) add(spiffeID string, conn *grpc.ClientConn) error {
+func (p *clientsPool) add(m *manager, spiffeID string, conn *grpc.ClientConn, bal *healthBalancer, svid *x509.Certificate, key *ecdsa.PrivateKey) error {
 	// If there is already a connection with the specified spiffeID, close it first.
 	if c := p.get(spiffeID); c != nil {
 		c.stream.CloseSend()
@@ -86,10 +159,108 @@ func (p *clientsPool) add(spiffeID string, conn *grpc.ClientConn) error {
 
 	p.m.Lock()
 	defer p.m.Unlock()
-	p.clients[spiffeID] = &client{conn: conn, stream: stream}
+	p.clients[spiffeID] = &client{
+		conn:          conn,
+		stream:        stream,
+		balancer:      bal,
+		spiffeID:      spiffeID,
+		svid:          svid,
+		key:           key,
+		m:             m,
+		pool:          p,
+		pingRequested: make(chan struct{}, 1),
+	}
 	return nil
 }
 
+// reconnect is called when a FetchSVID Recv returns anything other than
+// io.EOF, or a liveness probe fails: the conn is presumed broken (server restart, network partition,
+// failed TLS renegotiation, ...), so it's torn down and a fresh one dialed
+// and swapped into the pool atomically. Attempts are spaced out with a
+// jittered exponential backoff capped at reconnectMaxDelay; after
+// reconnectMaxRetries consecutive failures the error is surfaced through the
+// manager's Err() channel instead of retrying forever.
+func (c *client) reconnect(ctx context.Context) error {
+	oldConn := c.conn
+
+	delay := reconnectBaseDelay
+	var lastErr error
+	for attempt := 0; attempt < reconnectMaxRetries; attempt++ {
+		if attempt > 0 {
+			jittered := time.Duration(rand.Int63n(int64(delay)))
+			select {
+			case <-time.After(jittered):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if delay *= 2; delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+		}
+
+		conn, bal, err := c.m.newGRPCConn(c.svid, c.key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		stream, err := node.NewNodeClient(conn).FetchSVID(context.TODO())
+		if err != nil {
+			conn.Close()
+			lastErr = err
+			continue
+		}
+
+		c.pool.m.Lock()
+		c.conn = conn
+		c.stream = stream
+		c.balancer = bal
+		c.pool.clients[c.spiffeID] = c
+		c.pool.m.Unlock()
+
+		oldConn.Close()
+		return nil
+	}
+
+	c.m.setErr(lastErr)
+	return lastErr
+}
+
+// probeLive exercises the client's current endpoint with a throwaway
+// FetchSVID call, the same way a health-balancer probe does. A cached
+// connectivity.State isn't enough to catch a silently half-open TCP
+// connection: gRPC only flips out of Ready once something actually
+// attempts I/O on it, which is exactly the failure this is meant to catch
+// before the real FetchSVID stream hits it.
+func (c *client) probeLive() error {
+	return probeFetchSVID(c.m.tlsConfigFor(c.svid, c.key), c.balancer.Current())
+}
+
+// Ping asks every pool client to check its connection's liveness so a
+// silent half-open TCP connection gets noticed and recycled before it
+// would otherwise fail the next SVID rotation. It's meant to be driven by
+// a manager goroutine on an interval. The check itself runs on the
+// client's own syncClient goroutine (signaled here) rather than on the
+// caller, so it never races with syncClient's stream error handling over
+// the same client's conn/stream/balancer.
+func (p *clientsPool) Ping(ctx context.Context) {
+	p.m.Lock()
+	clients := make([]*client, 0, len(p.clients))
+	for _, c := range p.clients {
+		clients = append(clients, c)
+	}
+	p.m.Unlock()
+
+	for _, c := range clients {
+		select {
+		case c.pingRequested <- struct{}{}:
+		default:
+			// A probe is already pending for this client; no need to pile
+			// on another one.
+		}
+	}
+}
+
 func (p *clientsPool) get(spiffeID string) *client {
 	p.m.Lock()
 	defer p.m.Unlock()
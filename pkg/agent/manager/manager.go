@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/spire/pkg/agent/common/grpcmw"
+	"github.com/spiffe/spire/pkg/agent/manager/cache"
+	"github.com/spiffe/spire/pkg/agent/manager/cache/inmemory"
+)
+
+// CacheBackendFactory builds the Cache a manager uses to store fetched
+// SVIDs. Config.CacheBackend is one of these, selected by the agent's
+// cache_backend HCL block (e.g. cache/lru.New bound with its max_entries).
+type CacheBackendFactory func(log logrus.FieldLogger, metrics cache.Metrics) cache.Cache
+
+// Config configures a Manager.
+type Config struct {
+	SVID        *x509.Certificate
+	SVIDKey     *ecdsa.PrivateKey
+	Bundle      []*x509.Certificate
+	TrustDomain url.URL
+
+	// ServerAddr is kept for callers that only know about a single SPIRE
+	// server. ServerAddresses supersedes it: when set, the manager
+	// balances FetchSVID streams across every address in the list instead
+	// of dialing ServerAddr alone.
+	ServerAddr      net.Addr
+	ServerAddresses []net.Addr
+
+	Log             logrus.FieldLogger
+	BundleCachePath string
+	SVIDCachePath   string
+
+	// CacheBackend builds the cache.Cache the manager stores fetched SVIDs
+	// in. Defaults to cache/inmemory's unbounded map when nil.
+	CacheBackend CacheBackendFactory
+	// CacheMetrics is passed through to CacheBackend.
+	CacheMetrics cache.Metrics
+
+	// MetricsSink receives per-method call count, error count and latency
+	// for every RPC the manager issues against the SPIRE server.
+	MetricsSink grpcmw.Sink
+}
+
+// Manager fetches and caches the SVIDs the agent's registration entries are
+// entitled to, keeping them rotated for as long as it's running.
+type Manager interface {
+	// Start begins fetching and rotating SVIDs. It returns once the agent's
+	// FetchSVID stream is open, without waiting for a first response;
+	// failures after that point are reported through Err()/Stopped().
+	Start() error
+	// Stopped is closed once the manager has stopped running, whether
+	// because of Shutdown or an unrecoverable error.
+	Stopped() <-chan struct{}
+	// Err returns the error that stopped the manager, if any.
+	Err() error
+	// Shutdown stops the manager and releases its resources.
+	Shutdown()
+	// Cache returns the manager's SVID cache.
+	Cache() cache.Cache
+}
+
+type manager struct {
+	c *Config
+
+	serverSPIFFEID string
+	serverAddrs    []net.Addr
+	metricsSink    grpcmw.Sink
+
+	// mtx guards clients.
+	mtx     sync.Mutex
+	clients *clientsPool
+
+	cache cache.Cache
+	log   logrus.FieldLogger
+
+	cancel context.CancelFunc
+
+	errMtx   sync.Mutex
+	err      error
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a Manager from the given Config.
+func New(c *Config) (Manager, error) {
+	serverAddrs := c.ServerAddresses
+	if len(serverAddrs) == 0 && c.ServerAddr != nil {
+		serverAddrs = []net.Addr{c.ServerAddr}
+	}
+
+	cacheBackend := c.CacheBackend
+	if cacheBackend == nil {
+		cacheBackend = func(log logrus.FieldLogger, metrics cache.Metrics) cache.Cache {
+			return inmemory.New(log, metrics)
+		}
+	}
+
+	return &manager{
+		c:              c,
+		serverSPIFFEID: serverSPIFFEID(c.TrustDomain),
+		serverAddrs:    serverAddrs,
+		metricsSink:    c.MetricsSink,
+		cache:          cacheBackend(c.Log, c.CacheMetrics),
+		log:            c.Log,
+		stopped:        make(chan struct{}),
+	}, nil
+}
+
+// Start dials the SPIRE server(s), opens the agent's own FetchSVID stream,
+// and begins the background sync and Ping loops that keep it alive: syncClient
+// reconnects a client the moment its stream returns a non-EOF error, and
+// pingLoop recycles any client left idling on a half-open conn.
+func (m *manager) Start() error {
+	agentID := spiffeIDFromCert(m.c.SVID)
+	if err := m.newClient([]string{agentID}, m.c.SVID, m.c.SVIDKey); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	m.mtx.Lock()
+	clients := m.clients
+	m.mtx.Unlock()
+
+	clients.m.Lock()
+	for _, c := range clients.clients {
+		go m.syncClient(ctx, c)
+	}
+	clients.m.Unlock()
+
+	go m.pingLoop(ctx)
+
+	return nil
+}
+
+func (m *manager) Stopped() <-chan struct{} {
+	return m.stopped
+}
+
+func (m *manager) Err() error {
+	m.errMtx.Lock()
+	defer m.errMtx.Unlock()
+	return m.err
+}
+
+func (m *manager) Cache() cache.Cache {
+	return m.cache
+}
+
+func (m *manager) Shutdown() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mtx.Lock()
+	clients := m.clients
+	m.mtx.Unlock()
+	if clients != nil {
+		clients.close()
+	}
+	m.setErr(nil)
+}
+
+// setErr records the manager's terminal error, if any, and closes Stopped()
+// the first time it's called.
+func (m *manager) setErr(err error) {
+	m.errMtx.Lock()
+	if m.err == nil {
+		m.err = err
+	}
+	m.errMtx.Unlock()
+
+	m.stopOnce.Do(func() { close(m.stopped) })
+}
+
+func (m *manager) bundleAsCertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, c := range m.c.Bundle {
+		pool.AddCert(c)
+	}
+	return pool
+}
+
+// serverSPIFFEID returns the SPIFFE ID the agent expects to see presented by
+// the SPIRE server(s) it connects to.
+func serverSPIFFEID(trustDomain url.URL) string {
+	return "spiffe://" + trustDomain.Host + "/spire/server"
+}
+
+// spiffeIDFromCert returns the SPIFFE ID carried in a certificate's first
+// URI SAN, which is where go-spiffe places it.
+func spiffeIDFromCert(cert *x509.Certificate) string {
+	if cert == nil || len(cert.URIs) == 0 {
+		return ""
+	}
+	return cert.URIs[0].String()
+}
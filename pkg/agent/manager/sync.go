@@ -0,0 +1,192 @@
+package manager
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"time"
+
+	"github.com/spiffe/spire/pkg/agent/manager/cache"
+	"github.com/spiffe/spire/proto/api/node"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pingInterval is how often idle pool clients are asked to check their
+// connection's liveness, so a silent half-open TCP connection gets
+// recycled before the next SVID rotation deadline, rather than only being
+// noticed the next time it's used.
+const pingInterval = time.Minute
+
+// recvResult carries a single c.stream.Recv() outcome from the recvLoop
+// goroutine to the syncClient goroutine that owns the client.
+type recvResult struct {
+	resp *node.FetchSVIDResponse
+	err  error
+}
+
+// recvLoop does the one thing syncClient can't do without blocking: call
+// the (possibly long-blocking) c.stream.Recv(). It reads exactly one
+// response or error and hands it to syncClient over recvCh, then exits;
+// syncClient starts a fresh recvLoop against the current c.stream after
+// each reconnect.
+func (c *client) recvLoop(ctx context.Context, recvCh chan<- recvResult) {
+	resp, err := c.stream.Recv()
+	select {
+	case recvCh <- recvResult{resp: resp, err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// startRecvLoop spawns a fresh recvLoop against c's current stream and
+// returns the channel it reports on.
+func (c *client) startRecvLoop(ctx context.Context) chan recvResult {
+	recvCh := make(chan recvResult, 1)
+	go c.recvLoop(ctx, recvCh)
+	return recvCh
+}
+
+// syncClient runs for the lifetime of the manager, and is the only
+// goroutine that ever mutates its client's conn, stream and balancer:
+// that's what keeps a stream error and a pingLoop-driven liveness check
+// from racing to reconnect the same client twice. It reads FetchSVID
+// responses off a recvLoop goroutine and applies them to the cache, and
+// services pingRequested by actually probing the endpoint rather than
+// trusting a cached connectivity.State.
+func (m *manager) syncClient(ctx context.Context, c *client) {
+	recvCh := c.startRecvLoop(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case res := <-recvCh:
+			if res.err != nil {
+				if res.err == io.EOF {
+					return
+				}
+				if !m.handleClientError(ctx, c, isServerDown(res.err)) {
+					return
+				}
+				recvCh = c.startRecvLoop(ctx)
+				continue
+			}
+
+			m.applyUpdate(c, res.resp)
+			recvCh = c.startRecvLoop(ctx)
+
+		case <-c.pingRequested:
+			if err := c.probeLive(); err != nil {
+				m.log.WithField("spiffe_id", c.spiffeID).WithError(err).Debug("liveness probe failed, reconnecting")
+				if !m.handleClientError(ctx, c, true) {
+					return
+				}
+				recvCh = c.startRecvLoop(ctx)
+			}
+		}
+	}
+}
+
+// isServerDown reports whether a FetchSVID Recv error is one that takes the
+// endpoint out of rotation: Unavailable or DeadlineExceeded.
+func isServerDown(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// handleClientError reconnects the client and, when markDown is set, first
+// marks the endpoint the client's now-stale stream was actually routed to
+// as unhealthy (c.balancer.Current(), not c.conn.Target(): the latter is
+// always the conn's original dial target, not whichever address the
+// balancer's Get() last handed out). Returns false if reconnect gave up, in
+// which case the caller's syncClient loop should exit; m.setErr has already
+// recorded the failure.
+func (m *manager) handleClientError(ctx context.Context, c *client, markDown bool) bool {
+	if markDown && c.balancer != nil {
+		c.balancer.markUnhealthy(c.balancer.Current())
+	}
+
+	if rerr := c.reconnect(ctx); rerr != nil {
+		m.setErr(rerr)
+		return false
+	}
+	return true
+}
+
+// applyUpdate translates a FetchSVID response into cache entries and
+// stores them: SetEntry is what drives the Subscribe fan-out, so this is
+// the call that actually gets a rotated SVID in front of a subscribed
+// workload. update.RegistrationEntries is the agent's full current
+// entitlement set (not a delta), so any cached entry whose registration
+// entry isn't in it anymore has been revoked server-side and is dropped.
+func (m *manager) applyUpdate(c *client, resp *node.FetchSVIDResponse) {
+	update := resp.GetSvidUpdate()
+	if update == nil {
+		return
+	}
+
+	live := make(map[string]struct{}, len(update.RegistrationEntries))
+
+	for _, regEntry := range update.RegistrationEntries {
+		live[regEntry.SpiffeId] = struct{}{}
+
+		svidMsg, ok := update.Svids[regEntry.SpiffeId]
+		if !ok {
+			continue
+		}
+
+		svid, err := x509.ParseCertificate(svidMsg.Cert)
+		if err != nil {
+			m.log.WithError(err).WithField("spiffe_id", regEntry.SpiffeId).Error("failed to parse SVID from FetchSVID response")
+			continue
+		}
+
+		// SetEntry only ever appends, and Entry()/Entries() only ever
+		// serve the oldest element for a given registration entry, so a
+		// rotated SVID has to replace the previous one here rather than
+		// pile up behind it.
+		m.cache.DeleteEntries(regEntry)
+		m.cache.SetEntry(&cache.Entry{
+			RegistrationEntry: regEntry,
+			SVID:              svid,
+			PrivateKey:        c.key,
+		})
+	}
+
+	for entry := range m.cache.Entries() {
+		if entry.RegistrationEntry == nil {
+			continue
+		}
+		if _, ok := live[entry.RegistrationEntry.SpiffeId]; ok {
+			continue
+		}
+		m.cache.DeleteEntries(entry.RegistrationEntry)
+	}
+}
+
+// pingLoop periodically asks idle pool clients to check their liveness,
+// recycling any with a broken conn before the next SVID rotation deadline
+// would otherwise fail against it.
+func (m *manager) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mtx.Lock()
+			clients := m.clients
+			m.mtx.Unlock()
+			if clients != nil {
+				clients.Ping(ctx)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}